@@ -0,0 +1,359 @@
+package pool
+
+import (
+	"fmt"
+	"hash/crc32"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Dialer 抽象了与单个目标地址建立连接的方式，便于替换为自定义拨号逻辑
+type Dialer interface {
+	Dial(target string) (net.Conn, error)
+}
+
+// DialerFunc 是 Dialer 的函数适配器
+type DialerFunc func(target string) (net.Conn, error)
+
+// Dial 实现 Dialer 接口
+func (f DialerFunc) Dial(target string) (net.Conn, error) {
+	return f(target)
+}
+
+// netDialer 是默认的 TCP 拨号实现
+type netDialer struct {
+	timeout time.Duration
+}
+
+// Dial 实现 Dialer 接口
+func (d netDialer) Dial(target string) (net.Conn, error) {
+	return net.DialTimeout("tcp", target, d.timeout)
+}
+
+// Strategy 描述在多个目标间选择拨号目标的负载均衡策略
+type Strategy int
+
+const (
+	RoundRobin         Strategy = iota // 轮询
+	WeightedRoundRobin                 // 平滑加权轮询
+	HashSpread                         // 基于哈希环的分散拨号（非按键一致性哈希，见pickHashSpread）
+)
+
+const (
+	defaultFailThreshold = 3                // 默认连续失败多少次后熔断目标
+	defaultCooldown      = 10 * time.Second // 默认熔断冷却时间
+	defaultDialTimeout   = 5 * time.Second  // 默认拨号超时
+	ringVnodesPerWeight  = 20               // HashSpread每个权重单位对应的虚拟节点数
+)
+
+// TargetStats 描述单个目标地址的拨号健康状况，由 Pool.TargetStats 返回
+type TargetStats struct {
+	Target     string // 目标地址
+	Success    int64  // 累计拨号成功次数
+	Failure    int64  // 累计拨号失败次数
+	Healthy    bool   // 当前是否被判定为健康（未被熔断）
+	ConsecFail int    // 当前连续失败次数
+}
+
+// targetEntry 保存单个目标的运行时状态
+type targetEntry struct {
+	addr           string
+	weight         int
+	currentWeight  int
+	success        int64
+	failure        int64
+	consecFails    int
+	unhealthyUntil time.Time
+}
+
+// isHealthy 判断目标是否已过熔断冷却期
+func (t *targetEntry) isHealthy() bool {
+	return t.unhealthyUntil.IsZero() || time.Now().After(t.unhealthyUntil)
+}
+
+// ringNode 是HashSpread策略使用的哈希环上的一个虚拟节点
+type ringNode struct {
+	hash uint32
+	t    *targetEntry
+}
+
+// balancer 在多个目标之间按策略选择拨号目标，并维护每个目标的健康状态
+type balancer struct {
+	mu            sync.Mutex
+	targets       []*targetEntry
+	ring          []ringNode
+	strategy      Strategy
+	dialer        Dialer
+	failThreshold int
+	cooldown      time.Duration
+	rrIndex       int
+	seq           uint64
+	lastDialed    *targetEntry // 最近一次dial选中的目标，供上层报告握手结果使用
+}
+
+// newBalancer 根据目标列表和权重构建均衡器，某个目标在weights中缺项或权重<=0时按1处理
+func newBalancer(targets []string, weights []int, strategy Strategy, dialer Dialer, failThreshold int, cooldown time.Duration) *balancer {
+	if dialer == nil {
+		dialer = netDialer{timeout: defaultDialTimeout}
+	}
+	if failThreshold <= 0 {
+		failThreshold = defaultFailThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCooldown
+	}
+
+	entries := make([]*targetEntry, 0, len(targets))
+	for i, addr := range targets {
+		weight := 1
+		if i < len(weights) && weights[i] > 0 {
+			weight = weights[i]
+		}
+		entries = append(entries, &targetEntry{addr: addr, weight: weight})
+	}
+
+	b := &balancer{
+		targets:       entries,
+		strategy:      strategy,
+		dialer:        dialer,
+		failThreshold: failThreshold,
+		cooldown:      cooldown,
+	}
+	b.ring = buildRing(entries)
+	return b
+}
+
+// buildRing 为HashSpread策略构建虚拟节点环，虚拟节点数按权重比例分配
+func buildRing(targets []*targetEntry) []ringNode {
+	ring := make([]ringNode, 0, len(targets)*ringVnodesPerWeight)
+	for _, t := range targets {
+		n := t.weight * ringVnodesPerWeight
+		for i := 0; i < n; i++ {
+			h := crc32.ChecksumIEEE([]byte(fmt.Sprintf("%s#%d", t.addr, i)))
+			ring = append(ring, ringNode{hash: h, t: t})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	return ring
+}
+
+// pick 按配置的策略选出下一个健康的目标
+func (b *balancer) pick() *targetEntry {
+	switch b.strategy {
+	case WeightedRoundRobin:
+		return b.pickWeighted()
+	case HashSpread:
+		return b.pickHashSpread()
+	default:
+		return b.pickRoundRobin()
+	}
+}
+
+// pickRoundRobin 以轮询方式选出下一个健康目标
+func (b *balancer) pickRoundRobin() *targetEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.targets)
+	for i := 0; i < n; i++ {
+		b.rrIndex = (b.rrIndex + 1) % n
+		if t := b.targets[b.rrIndex]; t.isHealthy() {
+			return t
+		}
+	}
+	return nil
+}
+
+// pickWeighted 以平滑加权轮询算法选出下一个健康目标
+func (b *balancer) pickWeighted() *targetEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var best *targetEntry
+	totalWeight := 0
+	for _, t := range b.targets {
+		if !t.isHealthy() {
+			continue
+		}
+		t.currentWeight += t.weight
+		totalWeight += t.weight
+		if best == nil || t.currentWeight > best.currentWeight {
+			best = t
+		}
+	}
+	if best != nil {
+		best.currentWeight -= totalWeight
+	}
+	return best
+}
+
+// pickHashSpread 在哈希环上为一个递增的虚拟序号选出最近的健康目标。
+// 注意：此池在填充容量时提前批量拨号，拨号时并无调用方请求或键可用，
+// 因此这里选取的是一个单调递增序号而非真实请求键——得到的是按权重
+// 分散到各目标、但不具备"相同键命中相同目标"性质的哈希分散策略，
+// 并非严格意义上的一致性哈希，使用上应按HashSpread理解而非按键路由。
+func (b *balancer) pickHashSpread() *targetEntry {
+	seq := atomic.AddUint64(&b.seq, 1)
+	h := crc32.ChecksumIEEE([]byte(strconv.FormatUint(seq, 10)))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.ring)
+	if n == 0 {
+		return nil
+	}
+	start := sort.Search(n, func(i int) bool { return b.ring[i].hash >= h })
+	for i := 0; i < n; i++ {
+		if node := b.ring[(start+i)%n]; node.t.isHealthy() {
+			return node.t
+		}
+	}
+	return nil
+}
+
+// dial 选出一个目标并拨号，同时更新其健康统计与熔断状态。
+// 选中的目标会记录为lastDialed，供拨号成功后、握手失败时通过
+// reportHandshake补报一次失败，使熔断同时覆盖拨号与握手两个阶段。
+func (b *balancer) dial() (net.Conn, error) {
+	t := b.pick()
+	if t == nil {
+		return nil, fmt.Errorf("pool: no healthy target available")
+	}
+
+	b.mu.Lock()
+	b.lastDialed = t
+	b.mu.Unlock()
+
+	conn, err := b.dialer.Dial(t.addr)
+
+	b.mu.Lock()
+	b.recordResult(t, err)
+	b.mu.Unlock()
+
+	return conn, err
+}
+
+// reportHandshake 补报最近一次dial()选中目标的握手结果，用于在拨号成功
+// 但握手失败时同样计入连续失败次数，使目标能够因持续握手失败而被熔断。
+// 调用方须在同一Pool的单个拨号循环中于dial()之后紧邻调用，不支持并发拨号。
+func (b *balancer) reportHandshake(err error) {
+	if err == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.lastDialed == nil {
+		return
+	}
+	b.recordResult(b.lastDialed, err)
+}
+
+// recordResult 根据一次拨号或握手的结果更新目标的健康统计与熔断状态，调用方须持有b.mu
+func (b *balancer) recordResult(t *targetEntry, err error) {
+	if err != nil {
+		t.failure++
+		t.consecFails++
+		if t.consecFails >= b.failThreshold {
+			t.unhealthyUntil = time.Now().Add(b.cooldown)
+		}
+	} else {
+		t.success++
+		t.consecFails = 0
+		t.unhealthyUntil = time.Time{}
+	}
+}
+
+// probe 周期性地对已熔断目标发起探测拨号，成功则提前解除熔断
+func (b *balancer) probe(stop <-chan struct{}) {
+	ticker := time.NewTicker(b.cooldown)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			b.mu.Lock()
+			unhealthy := make([]*targetEntry, 0)
+			for _, t := range b.targets {
+				if !t.isHealthy() {
+					unhealthy = append(unhealthy, t)
+				}
+			}
+			b.mu.Unlock()
+
+			for _, t := range unhealthy {
+				conn, err := b.dialer.Dial(t.addr)
+				if err != nil {
+					continue
+				}
+				conn.Close()
+
+				b.mu.Lock()
+				t.success++
+				t.consecFails = 0
+				t.unhealthyUntil = time.Time{}
+				b.mu.Unlock()
+			}
+		}
+	}
+}
+
+// stats 返回所有目标当前的健康统计快照
+func (b *balancer) stats() []TargetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]TargetStats, 0, len(b.targets))
+	for _, t := range b.targets {
+		out = append(out, TargetStats{
+			Target:     t.addr,
+			Success:    t.success,
+			Failure:    t.failure,
+			Healthy:    t.isHealthy(),
+			ConsecFail: t.consecFails,
+		})
+	}
+	return out
+}
+
+// NewBalancedClientPool 创建支持多目标负载均衡与被动熔断的客户端连接池。
+// dialer 为空时使用默认的 TCP 拨号器；failThreshold、cooldown 小于等于0时使用默认值。
+func NewBalancedClientPool(
+	minCap, maxCap int,
+	minIvl, maxIvl time.Duration,
+	keepAlive time.Duration,
+	tlsCode string,
+	hostname string,
+	targets []string,
+	weights []int,
+	strategy Strategy,
+	dialer Dialer,
+	failThreshold int,
+	cooldown time.Duration,
+	ping func(net.Conn) error,
+	idleTimeout time.Duration,
+	handshakeTimeout time.Duration,
+	opts ...Option,
+) *Pool {
+	b := newBalancer(targets, weights, strategy, dialer, failThreshold, cooldown)
+
+	pool := NewClientPool(minCap, maxCap, minIvl, maxIvl, keepAlive, tlsCode, hostname, b.dial, ping, idleTimeout, handshakeTimeout, opts...)
+	pool.balancer = b
+	return pool
+}
+
+// TargetStats 返回多目标负载均衡拨号器中各目标的健康统计，非均衡拨号池返回nil
+func (p *Pool) TargetStats() []TargetStats {
+	if p.balancer == nil {
+		return nil
+	}
+	return p.balancer.stats()
+}
@@ -0,0 +1,10 @@
+package pool
+
+import "errors"
+
+// 连接池操作可能返回的哨兵错误
+var (
+	ErrPoolClosed    = errors.New("pool: closed")       // 连接池已关闭
+	ErrPoolExhausted = errors.New("pool: exhausted")    // 取到的连接未通过健康探测而被关闭，本次没有可用连接返回
+	ErrWaitTimeout   = errors.New("pool: wait timeout") // 调用方的ctx在等到连接之前结束（超过截止时间或被取消）
+)
@@ -0,0 +1,67 @@
+package pool
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"time"
+)
+
+// handshakeVersion 是当前握手帧格式的协议版本号
+const handshakeVersion byte = 1
+
+// writeHandshake 按 [1字节版本][1字节ID长度][ID][4字节CRC32] 的帧格式向对端写入连接ID
+func writeHandshake(conn net.Conn, id string) error {
+	idBytes := []byte(id)
+	if len(idBytes) > 255 {
+		return fmt.Errorf("pool: handshake id too long: %d bytes", len(idBytes))
+	}
+
+	frame := make([]byte, 0, 2+len(idBytes)+4)
+	frame = append(frame, handshakeVersion, byte(len(idBytes)))
+	frame = append(frame, idBytes...)
+
+	crcBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(crcBytes, crc32.ChecksumIEEE(frame))
+	frame = append(frame, crcBytes...)
+
+	_, err := conn.Write(frame)
+	return err
+}
+
+// readHandshake 在 timeout 截止时间内读取并校验握手帧，返回解析出的连接ID
+func readHandshake(conn net.Conn, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return "", err
+		}
+		defer conn.SetReadDeadline(time.Time{})
+	}
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+
+	version, idLen := header[0], header[1]
+	if version != handshakeVersion {
+		return "", fmt.Errorf("pool: handshake version mismatch: got %d", version)
+	}
+
+	payload := make([]byte, int(idLen)+4)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return "", err
+	}
+
+	id := payload[:idLen]
+	expected := binary.BigEndian.Uint32(payload[idLen:])
+
+	frame := append(header, id...)
+	if crc32.ChecksumIEEE(frame) != expected {
+		return "", fmt.Errorf("pool: handshake checksum mismatch")
+	}
+
+	return string(id), nil
+}
@@ -0,0 +1,133 @@
+package pool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// waitLatencyBound 描述等待延迟直方图中一个桶的标签与上界
+type waitLatencyBound struct {
+	label string
+	upper time.Duration
+}
+
+// waitLatencyBoundCount 是等待延迟直方图的桶数，必须与waitLatencyBounds的长度一致
+const waitLatencyBoundCount = 6
+
+// waitLatencyBounds 定义等待延迟直方图的桶边界，按上界从小到大排列
+var waitLatencyBounds = [waitLatencyBoundCount]waitLatencyBound{
+	{"<=10ms", 10 * time.Millisecond},
+	{"<=50ms", 50 * time.Millisecond},
+	{"<=200ms", 200 * time.Millisecond},
+	{"<=1s", time.Second},
+	{"<=5s", 5 * time.Second},
+	{">5s", time.Duration(1<<63 - 1)},
+}
+
+// PoolMetrics 是某一时刻连接池运行指标的快照
+type PoolMetrics struct {
+	Gets               int64            // Get/GetContext 调用总次数
+	Misses             int64            // 未能取到可用连接的次数
+	DialFailures       int64            // 拨号失败次数
+	HandshakeFailures  int64            // 握手失败次数
+	Evictions          int64            // 被回收器清理、或在ServerGet/ServerGetContext出列时发现失活而丢弃的连接数
+	Rejected           int64            // 被限流或配额拒绝的连接数
+	WaitLatencyBuckets map[string]int64 // 按延迟区间统计的等待耗时直方图
+	Capacity           int              // 当前容量
+	Interval           time.Duration    // 当前连接创建间隔
+}
+
+// hooks 保存可选的可观测性回调，字段为nil时跳过调用
+type hooks struct {
+	onDial      func(err error)
+	onHandshake func(err error)
+	onEvict     func(id string)
+	onGet       func(id string, hit bool)
+}
+
+// metrics 使用 sync/atomic 维护计数器，避免在热路径上引入锁竞争
+type metrics struct {
+	gets              int64
+	misses            int64
+	dialFailures      int64
+	handshakeFailures int64
+	evictions         int64
+	rejected          int64
+	waitBuckets       [len(waitLatencyBounds)]int64
+}
+
+// recordGet 记录一次Get调用及其是否命中
+func (m *metrics) recordGet(hit bool) {
+	atomic.AddInt64(&m.gets, 1)
+	if !hit {
+		atomic.AddInt64(&m.misses, 1)
+	}
+}
+
+// recordDialFailure 记录一次拨号失败
+func (m *metrics) recordDialFailure() {
+	atomic.AddInt64(&m.dialFailures, 1)
+}
+
+// recordHandshakeFailure 记录一次握手失败
+func (m *metrics) recordHandshakeFailure() {
+	atomic.AddInt64(&m.handshakeFailures, 1)
+}
+
+// recordEviction 记录一次连接被回收
+func (m *metrics) recordEviction() {
+	atomic.AddInt64(&m.evictions, 1)
+}
+
+// recordRejection 记录一次因限流或配额被拒绝的连接
+func (m *metrics) recordRejection() {
+	atomic.AddInt64(&m.rejected, 1)
+}
+
+// recordWait 将一次等待耗时归入对应的直方图桶
+func (m *metrics) recordWait(d time.Duration) {
+	for i, b := range waitLatencyBounds {
+		if d <= b.upper {
+			atomic.AddInt64(&m.waitBuckets[i], 1)
+			return
+		}
+	}
+}
+
+// snapshot 生成当前指标的只读快照
+func (m *metrics) snapshot(capacity int, interval time.Duration) PoolMetrics {
+	buckets := make(map[string]int64, len(waitLatencyBounds))
+	for i, b := range waitLatencyBounds {
+		buckets[b.label] = atomic.LoadInt64(&m.waitBuckets[i])
+	}
+
+	return PoolMetrics{
+		Gets:               atomic.LoadInt64(&m.gets),
+		Misses:             atomic.LoadInt64(&m.misses),
+		DialFailures:       atomic.LoadInt64(&m.dialFailures),
+		HandshakeFailures:  atomic.LoadInt64(&m.handshakeFailures),
+		Evictions:          atomic.LoadInt64(&m.evictions),
+		Rejected:           atomic.LoadInt64(&m.rejected),
+		WaitLatencyBuckets: buckets,
+		Capacity:           capacity,
+		Interval:           interval,
+	}
+}
+
+// Option 是用于配置 Pool 可选行为的函数式选项，传给 NewClientPool/NewServerPool/NewBalancedClientPool
+type Option func(*Pool)
+
+// WithHooks 注册可观测性回调；任意回调参数均可传nil表示不关心该事件
+func WithHooks(onDial func(err error), onHandshake func(err error), onEvict func(id string), onGet func(id string, hit bool)) Option {
+	return func(p *Pool) {
+		p.hooks.onDial = onDial
+		p.hooks.onHandshake = onHandshake
+		p.hooks.onEvict = onEvict
+		p.hooks.onGet = onGet
+	}
+}
+
+// Metrics 返回当前连接池的指标快照
+func (p *Pool) Metrics() PoolMetrics {
+	return p.metrics.snapshot(p.capacity, p.interval)
+}
@@ -1,422 +1,788 @@
-// Package pool 实现了一个高性能、可靠的网络连接池管理系统
-package pool
-
-import (
-	"context"
-	"crypto/rand"
-	"crypto/tls"
-	"encoding/hex"
-	"net"
-	"sync"
-	"time"
-)
-
-// Pool 连接池结构体，用于管理多个网络连接
-type Pool struct {
-	mu        sync.Mutex               // 互斥锁，保护共享资源访问
-	conns     sync.Map                 // 存储连接的映射表
-	idChan    chan string              // 可用ID通道
-	tlsCode   string                   // TLS安全模式代码
-	hostname  string                   // 主机名
-	clientIP  string                   // 客户端IP
-	tlsConfig *tls.Config              // TLS配置
-	dialer    func() (net.Conn, error) // 创建连接的函数
-	listener  net.Listener             // 监听器
-	errCount  int                      // 错误计数
-	capacity  int                      // 当前容量
-	minCap    int                      // 最小容量
-	maxCap    int                      // 最大容量
-	interval  time.Duration            // 连接创建间隔
-	minIvl    time.Duration            // 最小间隔
-	maxIvl    time.Duration            // 最大间隔
-	keepAlive time.Duration            // 保活间隔
-	ctx       context.Context          // 上下文
-	cancel    context.CancelFunc       // 取消函数
-}
-
-// NewClientPool 创建新的客户端连接池
-func NewClientPool(
-	minCap, maxCap int,
-	minIvl, maxIvl time.Duration,
-	keepAlive time.Duration,
-	tlsCode string,
-	hostname string,
-	dialer func() (net.Conn, error),
-) *Pool {
-	if minCap <= 0 {
-		minCap = 1
-	}
-	if maxCap <= 0 {
-		maxCap = 1
-	}
-	if minCap > maxCap {
-		minCap, maxCap = maxCap, minCap
-	}
-
-	if minIvl <= 0 {
-		minIvl = time.Second
-	}
-	if maxIvl <= 0 {
-		maxIvl = time.Second
-	}
-	if minIvl > maxIvl {
-		minIvl, maxIvl = maxIvl, minIvl
-	}
-
-	return &Pool{
-		conns:     sync.Map{},
-		idChan:    make(chan string, maxCap),
-		tlsCode:   tlsCode,
-		hostname:  hostname,
-		dialer:    dialer,
-		capacity:  minCap,
-		minCap:    minCap,
-		maxCap:    maxCap,
-		interval:  minIvl,
-		minIvl:    minIvl,
-		maxIvl:    maxIvl,
-		keepAlive: keepAlive,
-	}
-}
-
-// NewServerPool 创建新的服务器连接池
-func NewServerPool(
-	clientIP string,
-	tlsConfig *tls.Config,
-	listener net.Listener,
-	keepAlive time.Duration,
-) *Pool {
-	maxCap := 65536
-	if listener == nil {
-		return nil
-	}
-
-	return &Pool{
-		conns:     sync.Map{},
-		idChan:    make(chan string, maxCap),
-		clientIP:  clientIP,
-		tlsConfig: tlsConfig,
-		listener:  listener,
-		maxCap:    maxCap,
-		keepAlive: keepAlive,
-	}
-}
-
-// ClientManager 客户端连接池管理器，负责创建和维护客户端连接
-func (p *Pool) ClientManager() {
-	if p.cancel != nil {
-		p.cancel()
-	}
-	p.ctx, p.cancel = context.WithCancel(context.Background())
-	var mu sync.Mutex
-
-	for {
-		select {
-		case <-p.ctx.Done():
-			return
-		default:
-			if !mu.TryLock() {
-				continue
-			}
-
-			p.adjustInterval()
-			created := 0
-
-			// 填充连接池至目标容量
-			for len(p.idChan) < p.capacity {
-				conn, err := p.dialer()
-				if err != nil {
-					continue
-				}
-
-				// 根据TLS代码应用不同级别的TLS安全
-				switch p.tlsCode {
-				case "0":
-					// 不使用TLS
-				case "1":
-					// 使用自签名证书（不验证）
-					tlsConn := tls.Client(conn, &tls.Config{
-						InsecureSkipVerify: true,
-						MinVersion:         tls.VersionTLS13,
-					})
-					err := tlsConn.Handshake()
-					if err != nil {
-						conn.Close()
-						continue
-					}
-					conn = tlsConn
-				case "2":
-					// 使用验证证书（安全模式）
-					tlsConn := tls.Client(conn, &tls.Config{
-						InsecureSkipVerify: false,
-						MinVersion:         tls.VersionTLS13,
-						ServerName:         p.hostname,
-					})
-					err := tlsConn.Handshake()
-					if err != nil {
-						conn.Close()
-						continue
-					}
-					conn = tlsConn
-				}
-
-				// 读取连接ID
-				buf := make([]byte, 8)
-				n, err := conn.Read(buf)
-				if err != nil || n != 8 {
-					conn.Close()
-					continue
-				}
-
-				conn.(*net.TCPConn).SetKeepAlive(true)
-				conn.(*net.TCPConn).SetKeepAlivePeriod(p.keepAlive)
-
-				id := string(buf[:n])
-				select {
-				case p.idChan <- id:
-					p.conns.Store(id, conn)
-					created++
-				default:
-					conn.Close()
-				}
-			}
-
-			p.adjustCapacity(created)
-			mu.Unlock()
-			time.Sleep(p.interval)
-		}
-	}
-}
-
-// ServerManager 服务器连接池管理器，负责接受和管理新连接
-func (p *Pool) ServerManager() {
-	if p.cancel != nil {
-		p.cancel()
-	}
-	p.ctx, p.cancel = context.WithCancel(context.Background())
-
-	for {
-		select {
-		case <-p.ctx.Done():
-			return
-		default:
-			conn, err := p.listener.Accept()
-			if err != nil {
-				continue
-			}
-
-			// 验证客户端IP（如果指定）
-			if p.clientIP != "" && conn.RemoteAddr().(*net.TCPAddr).IP.String() != p.clientIP {
-				conn.Close()
-				continue
-			}
-
-			// 应用TLS（如果配置）
-			if p.tlsConfig != nil {
-				tlsConn := tls.Server(conn, p.tlsConfig)
-				err := tlsConn.Handshake()
-				if err != nil {
-					conn.Close()
-					continue
-				}
-				conn = tlsConn
-			}
-
-			// 生成并发送连接ID
-			id := p.getID()
-			if _, exist := p.conns.Load(id); exist {
-				conn.Close()
-				continue
-			}
-
-			_, err = conn.Write([]byte(id))
-			if err != nil {
-				conn.Close()
-				continue
-			}
-
-			conn.(*net.TCPConn).SetKeepAlive(true)
-			conn.(*net.TCPConn).SetKeepAlivePeriod(p.keepAlive)
-
-			select {
-			case p.idChan <- id:
-				p.conns.Store(id, conn)
-			default:
-				conn.Close()
-			}
-		}
-	}
-}
-
-// ClientGet 获取指定ID的客户端连接
-func (p *Pool) ClientGet(id string) net.Conn {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	if conn, ok := p.conns.LoadAndDelete(id); ok {
-		p.removeID(id)
-		return conn.(net.Conn)
-	}
-	return nil
-}
-
-// ServerGet 获取一个可用的服务器连接及其ID
-func (p *Pool) ServerGet() (string, net.Conn) {
-	for {
-		select {
-		case id := <-p.idChan:
-			if conn, ok := p.conns.LoadAndDelete(id); ok {
-				netConn := conn.(net.Conn)
-				if p.isActive(netConn) {
-					return id, netConn
-				}
-				netConn.Close()
-			}
-		case <-p.ctx.Done():
-			return p.ctx.Err().Error(), nil
-		}
-	}
-}
-
-// Flush 清空连接池中的所有连接
-func (p *Pool) Flush() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	var wg sync.WaitGroup
-	p.conns.Range(func(key, value any) bool {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			value.(net.Conn).Close()
-		}()
-		return true
-	})
-	wg.Wait()
-
-	p.conns = sync.Map{}
-	p.idChan = make(chan string, p.maxCap)
-}
-
-// Close 关闭连接池并释放资源
-func (p *Pool) Close() {
-	if p.cancel != nil {
-		p.cancel()
-	}
-	p.Flush()
-}
-
-// Ready 检查连接池是否已初始化
-func (p *Pool) Ready() bool {
-	return p.ctx != nil
-}
-
-// Active 获取当前活跃连接数
-func (p *Pool) Active() int {
-	return len(p.idChan)
-}
-
-// Capacity 获取当前连接池容量
-func (p *Pool) Capacity() int {
-	return p.capacity
-}
-
-// Interval 获取当前连接创建间隔
-func (p *Pool) Interval() time.Duration {
-	return p.interval
-}
-
-// AddError 增加错误计数
-func (p *Pool) AddError() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.errCount++
-}
-
-// ErrorCount 获取错误计数
-func (p *Pool) ErrorCount() int {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	return p.errCount
-}
-
-// getID 生成唯一的连接ID
-func (p *Pool) getID() string {
-	bytes := make([]byte, 4)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes)
-}
-
-// removeID 从ID通道中移除指定ID
-func (p *Pool) removeID(id string) {
-	var wg sync.WaitGroup
-	tmpChan := make(chan string, p.maxCap)
-
-	for {
-		select {
-		case tmp := <-p.idChan:
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				if tmp != id {
-					tmpChan <- tmp
-				}
-			}()
-		default:
-			wg.Wait()
-			p.idChan = tmpChan
-			return
-		}
-	}
-}
-
-// adjustInterval 根据连接池使用情况动态调整连接创建间隔
-func (p *Pool) adjustInterval() {
-	idle := len(p.idChan)
-
-	if idle < p.capacity*2/10 && p.interval > p.minIvl {
-		p.interval -= 100 * time.Millisecond
-		if p.interval < p.minIvl {
-			p.interval = p.minIvl
-		}
-	}
-
-	if idle > p.capacity*8/10 && p.interval < p.maxIvl {
-		p.interval += 100 * time.Millisecond
-		if p.interval > p.maxIvl {
-			p.interval = p.maxIvl
-		}
-	}
-}
-
-// adjustCapacity 根据创建成功率动态调整连接池容量
-func (p *Pool) adjustCapacity(created int) {
-	ratio := float64(created) / float64(p.capacity)
-
-	if ratio < 0.2 && p.capacity > p.minCap {
-		p.capacity--
-	}
-
-	if ratio > 0.8 && p.capacity < p.maxCap {
-		p.capacity++
-	}
-}
-
-// isActive 检查连接是否处于活跃状态
-func (p *Pool) isActive(conn net.Conn) bool {
-	if err := conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
-		return false
-	}
-
-	_, err := conn.Read(make([]byte, 1))
-
-	if err := conn.SetReadDeadline(time.Time{}); err != nil {
-		return false
-	}
-
-	if err, ok := err.(net.Error); ok && err.Timeout() {
-		return true
-	}
-
-	return false
-}
+// Package pool 实现了一个高性能、可靠的网络连接池管理系统
+package pool
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+	"sync"
+	"time"
+)
+
+// connEntry 保存池中缓存连接及其入池时间，用于空闲超时判定
+type connEntry struct {
+	conn       net.Conn
+	insertedAt time.Time
+	remoteIP   string // 服务器端连接对应的客户端IP，供限流器归还配额使用
+}
+
+// Pool 连接池结构体，用于管理多个网络连接
+type Pool struct {
+	mu               sync.Mutex               // 互斥锁，保护共享资源访问
+	conns            sync.Map                 // 存储连接的映射表（值类型为 connEntry）
+	freeList         *list.List               // 空闲ID列表（元素类型为string），按到达顺序排列
+	freeIndex        map[string]*list.Element // ID到空闲列表节点的索引，用于O(1)定位与删除
+	freeSem          chan struct{}            // 容量为maxCap的信号量，用于空闲ID的等待/通知
+	tlsCode          string                   // TLS安全模式代码
+	hostname         string                   // 主机名
+	allowlist        []*net.IPNet             // 允许接入的客户端CIDR网段列表，为空表示不限制
+	rateLimiter      *rateLimiter             // 服务器端限流与连接配额控制器，为空表示不限制
+	tlsConfig        *tls.Config              // TLS配置
+	dialer           func() (net.Conn, error) // 创建连接的函数
+	listener         net.Listener             // 监听器
+	errCount         int                      // 错误计数
+	capacity         int                      // 当前容量
+	minCap           int                      // 最小容量
+	maxCap           int                      // 最大容量
+	interval         time.Duration            // 连接创建间隔
+	minIvl           time.Duration            // 最小间隔
+	maxIvl           time.Duration            // 最大间隔
+	keepAlive        time.Duration            // 保活间隔
+	ping             func(net.Conn) error     // 连接健康探测函数，为空则跳过探测
+	idleTimeout      time.Duration            // 连接最大空闲时间，超过则被回收器清理
+	handshakeTimeout time.Duration            // 读取握手帧的超时时间
+	waiters          map[string]chan net.Conn // 等待指定ID连接到达的调用方通道
+	balancer         *balancer                // 多目标负载均衡拨号器，非均衡拨号池为nil
+	metrics          metrics                  // 可观测性计数器
+	hooks            hooks                    // 可选的事件回调
+	ctx              context.Context          // 上下文
+	cancel           context.CancelFunc       // 取消函数
+}
+
+// NewClientPool 创建新的客户端连接池
+func NewClientPool(
+	minCap, maxCap int,
+	minIvl, maxIvl time.Duration,
+	keepAlive time.Duration,
+	tlsCode string,
+	hostname string,
+	dialer func() (net.Conn, error),
+	ping func(net.Conn) error,
+	idleTimeout time.Duration,
+	handshakeTimeout time.Duration,
+	opts ...Option,
+) *Pool {
+	if minCap <= 0 {
+		minCap = 1
+	}
+	if maxCap <= 0 {
+		maxCap = 1
+	}
+	if minCap > maxCap {
+		minCap, maxCap = maxCap, minCap
+	}
+
+	if minIvl <= 0 {
+		minIvl = time.Second
+	}
+	if maxIvl <= 0 {
+		maxIvl = time.Second
+	}
+	if minIvl > maxIvl {
+		minIvl, maxIvl = maxIvl, minIvl
+	}
+
+	p := &Pool{
+		conns:            sync.Map{},
+		freeList:         list.New(),
+		freeIndex:        make(map[string]*list.Element),
+		freeSem:          make(chan struct{}, maxCap),
+		tlsCode:          tlsCode,
+		hostname:         hostname,
+		dialer:           dialer,
+		capacity:         minCap,
+		minCap:           minCap,
+		maxCap:           maxCap,
+		interval:         minIvl,
+		minIvl:           minIvl,
+		maxIvl:           maxIvl,
+		keepAlive:        keepAlive,
+		ping:             ping,
+		idleTimeout:      idleTimeout,
+		handshakeTimeout: handshakeTimeout,
+		waiters:          make(map[string]chan net.Conn),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// NewServerPool 创建新的服务器连接池。allowlist 为空表示不限制来源网段，
+// rateLimit 的各字段为零值表示对应维度不限流。
+func NewServerPool(
+	allowlist []*net.IPNet,
+	tlsConfig *tls.Config,
+	listener net.Listener,
+	keepAlive time.Duration,
+	ping func(net.Conn) error,
+	idleTimeout time.Duration,
+	rateLimit RateLimit,
+	opts ...Option,
+) *Pool {
+	maxCap := 65536
+	if listener == nil {
+		return nil
+	}
+
+	p := &Pool{
+		conns:       sync.Map{},
+		freeList:    list.New(),
+		freeIndex:   make(map[string]*list.Element),
+		freeSem:     make(chan struct{}, maxCap),
+		allowlist:   allowlist,
+		rateLimiter: newRateLimiter(rateLimit),
+		tlsConfig:   tlsConfig,
+		listener:    listener,
+		maxCap:      maxCap,
+		keepAlive:   keepAlive,
+		ping:        ping,
+		idleTimeout: idleTimeout,
+		waiters:     make(map[string]chan net.Conn),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// ClientManager 客户端连接池管理器，负责创建和维护客户端连接
+func (p *Pool) ClientManager() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	var mu sync.Mutex
+
+	go p.reaper()
+	if p.balancer != nil {
+		go p.balancer.probe(p.ctx.Done())
+	}
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		default:
+			if !mu.TryLock() {
+				continue
+			}
+
+			p.adjustInterval()
+			created := 0
+
+			// 填充连接池至目标容量
+			for p.freeLen() < p.capacity {
+				conn, err := p.dialer()
+				if p.hooks.onDial != nil {
+					p.hooks.onDial(err)
+				}
+				if err != nil {
+					p.metrics.recordDialFailure()
+					continue
+				}
+
+				// 根据TLS代码应用不同级别的TLS安全
+				switch p.tlsCode {
+				case "0":
+					// 不使用TLS
+				case "1":
+					// 使用自签名证书（不验证）
+					tlsConn := tls.Client(conn, &tls.Config{
+						InsecureSkipVerify: true,
+						MinVersion:         tls.VersionTLS13,
+					})
+					err := tlsConn.Handshake()
+					if err != nil {
+						conn.Close()
+						continue
+					}
+					conn = tlsConn
+				case "2":
+					// 使用验证证书（安全模式）
+					tlsConn := tls.Client(conn, &tls.Config{
+						InsecureSkipVerify: false,
+						MinVersion:         tls.VersionTLS13,
+						ServerName:         p.hostname,
+					})
+					err := tlsConn.Handshake()
+					if err != nil {
+						conn.Close()
+						continue
+					}
+					conn = tlsConn
+				}
+
+				// 读取并校验握手帧，解析出连接ID
+				id, err := readHandshake(conn, p.handshakeTimeout)
+				if p.balancer != nil {
+					p.balancer.reportHandshake(err)
+				}
+				if p.hooks.onHandshake != nil {
+					p.hooks.onHandshake(err)
+				}
+				if err != nil {
+					conn.Close()
+					p.AddError()
+					p.metrics.recordHandshakeFailure()
+					continue
+				}
+
+				conn.(*net.TCPConn).SetKeepAlive(true)
+				conn.(*net.TCPConn).SetKeepAlivePeriod(p.keepAlive)
+
+				if p.deliverToWaiter(id, conn) {
+					created++
+					continue
+				}
+
+				p.conns.Store(id, connEntry{conn: conn, insertedAt: time.Now()})
+				if p.pushFreeIfRoom(id) {
+					created++
+				} else {
+					p.conns.Delete(id)
+					conn.Close()
+				}
+			}
+
+			p.adjustCapacity(created)
+			mu.Unlock()
+			time.Sleep(p.interval)
+		}
+	}
+}
+
+// ServerManager 服务器连接池管理器，负责接受和管理新连接
+func (p *Pool) ServerManager() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	go p.reaper()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		default:
+			conn, err := p.listener.Accept()
+			if err != nil {
+				continue
+			}
+
+			remoteIP := conn.RemoteAddr().(*net.TCPAddr).IP
+
+			// 验证客户端IP是否在允许的CIDR网段内（如果指定）
+			if len(p.allowlist) > 0 && !ipAllowed(p.allowlist, remoteIP) {
+				conn.Close()
+				continue
+			}
+
+			// 在TLS握手之前评估限流与连接配额，防止基于握手的DoS
+			remoteIPStr := remoteIP.String()
+			if p.rateLimiter != nil && !p.rateLimiter.allow(remoteIPStr) {
+				p.metrics.recordRejection()
+				conn.Close()
+				continue
+			}
+
+			// 应用TLS（如果配置）
+			if p.tlsConfig != nil {
+				tlsConn := tls.Server(conn, p.tlsConfig)
+				err := tlsConn.Handshake()
+				if err != nil {
+					p.rateLimiter.release(remoteIPStr)
+					conn.Close()
+					continue
+				}
+				conn = tlsConn
+			}
+
+			// 生成并发送连接ID
+			id := p.getID()
+			if _, exist := p.conns.Load(id); exist {
+				p.rateLimiter.release(remoteIPStr)
+				conn.Close()
+				continue
+			}
+
+			if err := writeHandshake(conn, id); err != nil {
+				if p.hooks.onHandshake != nil {
+					p.hooks.onHandshake(err)
+				}
+				p.metrics.recordHandshakeFailure()
+				p.rateLimiter.release(remoteIPStr)
+				conn.Close()
+				continue
+			}
+
+			conn.(*net.TCPConn).SetKeepAlive(true)
+			conn.(*net.TCPConn).SetKeepAlivePeriod(p.keepAlive)
+
+			p.conns.Store(id, connEntry{conn: conn, insertedAt: time.Now(), remoteIP: remoteIPStr})
+			if !p.pushFreeIfRoom(id) {
+				p.conns.Delete(id)
+				p.rateLimiter.release(remoteIPStr)
+				conn.Close()
+			}
+		}
+	}
+}
+
+// ClientGet 获取指定ID的客户端连接
+func (p *Pool) ClientGet(id string) net.Conn {
+	start := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if value, ok := p.conns.LoadAndDelete(id); ok {
+		p.removeID(id)
+		entry := value.(connEntry)
+		p.releaseRateLimit(entry)
+		conn := entry.conn
+		if p.ping != nil && p.ping(conn) != nil {
+			conn.Close()
+			p.recordGetResult(start, id, false)
+			return nil
+		}
+		p.recordGetResult(start, id, true)
+		return conn
+	}
+	p.recordGetResult(start, id, false)
+	return nil
+}
+
+// recordGetResult 统一记录一次Get调用的指标与等待耗时，并触发OnGet回调
+func (p *Pool) recordGetResult(start time.Time, id string, hit bool) {
+	p.metrics.recordGet(hit)
+	p.metrics.recordWait(time.Since(start))
+	if p.hooks.onGet != nil {
+		p.hooks.onGet(id, hit)
+	}
+}
+
+// releaseRateLimit 在连接离开连接池时归还其占用的限流配额（若启用了限流）
+func (p *Pool) releaseRateLimit(entry connEntry) {
+	if p.rateLimiter != nil && entry.remoteIP != "" {
+		p.rateLimiter.release(entry.remoteIP)
+	}
+}
+
+// recordDeadConn 记录ServerGet/ServerGetContext在出列时发现的一个失活连接。
+// 这类连接不会作为此次调用的结果返回（调用方会继续循环取下一个），
+// 但仍应计入Evictions并触发OnEvict，使这类连接池内部的死连接churn
+// 能够通过Metrics()观测到，而不是对调用方完全不可见。
+func (p *Pool) recordDeadConn(id string) {
+	p.metrics.recordEviction()
+	if p.hooks.onEvict != nil {
+		p.hooks.onEvict(id)
+	}
+}
+
+// deliverToWaiter 若存在正在等待该ID的调用方，则直接将连接交给它，返回是否交付成功
+func (p *Pool) deliverToWaiter(id string, conn net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	waiter, ok := p.waiters[id]
+	if !ok {
+		return false
+	}
+	delete(p.waiters, id)
+
+	// 在持有p.mu时投递（waiter缓冲为1，不会阻塞），使取消分支加锁之后
+	// 调用的closeOrphanedWaiter一定能看到已投递的连接
+	select {
+	case waiter <- conn:
+		return true
+	default:
+		return false
+	}
+}
+
+// ClientGetContext 阻塞等待指定ID的客户端连接到达，直至成功、ctx结束或连接池关闭
+func (p *Pool) ClientGetContext(ctx context.Context, id string) (net.Conn, error) {
+	start := time.Now()
+
+	p.mu.Lock()
+	if p.ctx == nil || p.ctx.Err() != nil {
+		p.mu.Unlock()
+		p.recordGetResult(start, id, false)
+		return nil, ErrPoolClosed
+	}
+
+	if value, ok := p.conns.LoadAndDelete(id); ok {
+		p.removeID(id)
+		p.mu.Unlock()
+		entry := value.(connEntry)
+		p.releaseRateLimit(entry)
+		conn := entry.conn
+		if p.ping != nil && p.ping(conn) != nil {
+			conn.Close()
+			p.recordGetResult(start, id, false)
+			return nil, ErrPoolExhausted
+		}
+		p.recordGetResult(start, id, true)
+		return conn, nil
+	}
+
+	waiter := make(chan net.Conn, 1)
+	p.waiters[id] = waiter
+	p.mu.Unlock()
+
+	select {
+	case conn := <-waiter:
+		if p.ping != nil && p.ping(conn) != nil {
+			conn.Close()
+			p.recordGetResult(start, id, false)
+			return nil, ErrPoolExhausted
+		}
+		p.recordGetResult(start, id, true)
+		return conn, nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		if p.waiters[id] == waiter {
+			delete(p.waiters, id)
+		}
+		p.mu.Unlock()
+		p.closeOrphanedWaiter(waiter)
+		p.recordGetResult(start, id, false)
+		return nil, ErrWaitTimeout
+	case <-p.ctx.Done():
+		p.mu.Lock()
+		if p.waiters[id] == waiter {
+			delete(p.waiters, id)
+		}
+		p.mu.Unlock()
+		p.closeOrphanedWaiter(waiter)
+		p.recordGetResult(start, id, false)
+		return nil, ErrPoolClosed
+	}
+}
+
+// closeOrphanedWaiter 在取消分支被select选中之后，非阻塞地检查deliverToWaiter
+// 是否恰好已在同一时刻把连接投递进了这个缓冲为1的waiter通道——select对多个
+// 就绪分支的选择是伪随机的，取消分支被选中并不代表没有连接到达。若存在这样
+// 一个连接，这里负责关闭它，避免其既未被返回给调用方、也未被放回连接池而泄漏。
+func (p *Pool) closeOrphanedWaiter(waiter chan net.Conn) {
+	select {
+	case conn := <-waiter:
+		conn.Close()
+	default:
+	}
+}
+
+// ServerGet 获取一个可用的服务器连接及其ID
+func (p *Pool) ServerGet() (string, net.Conn) {
+	start := time.Now()
+
+	for {
+		select {
+		case <-p.freeSem:
+			id, ok := p.popFree()
+			if !ok {
+				continue
+			}
+			if value, ok := p.conns.LoadAndDelete(id); ok {
+				entry := value.(connEntry)
+				p.releaseRateLimit(entry)
+				conn := entry.conn
+				if !p.isActive(conn) {
+					conn.Close()
+					p.recordDeadConn(id)
+					continue
+				}
+				if p.ping != nil && p.ping(conn) != nil {
+					conn.Close()
+					p.recordDeadConn(id)
+					continue
+				}
+				p.recordGetResult(start, id, true)
+				return id, conn
+			}
+		case <-p.ctx.Done():
+			p.recordGetResult(start, "", false)
+			return p.ctx.Err().Error(), nil
+		}
+	}
+}
+
+// ServerGetContext 阻塞等待一个可用的服务器连接，直至成功、ctx结束或连接池关闭
+func (p *Pool) ServerGetContext(ctx context.Context) (string, net.Conn, error) {
+	start := time.Now()
+
+	for {
+		select {
+		case <-p.freeSem:
+			id, ok := p.popFree()
+			if !ok {
+				continue
+			}
+			if value, ok := p.conns.LoadAndDelete(id); ok {
+				entry := value.(connEntry)
+				p.releaseRateLimit(entry)
+				conn := entry.conn
+				if !p.isActive(conn) {
+					conn.Close()
+					p.recordDeadConn(id)
+					continue
+				}
+				if p.ping != nil && p.ping(conn) != nil {
+					conn.Close()
+					p.recordDeadConn(id)
+					continue
+				}
+				p.recordGetResult(start, id, true)
+				return id, conn, nil
+			}
+		case <-ctx.Done():
+			p.recordGetResult(start, "", false)
+			return "", nil, ErrWaitTimeout
+		case <-p.ctx.Done():
+			p.recordGetResult(start, "", false)
+			return "", nil, ErrPoolClosed
+		}
+	}
+}
+
+// Flush 清空连接池中的所有连接
+func (p *Pool) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	p.conns.Range(func(key, value any) bool {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entry := value.(connEntry)
+			entry.conn.Close()
+			p.releaseRateLimit(entry)
+		}()
+		return true
+	})
+	wg.Wait()
+
+	p.conns = sync.Map{}
+	p.freeList = list.New()
+	p.freeIndex = make(map[string]*list.Element)
+	p.freeSem = make(chan struct{}, p.maxCap)
+	p.waiters = make(map[string]chan net.Conn)
+}
+
+// Close 关闭连接池并释放资源
+func (p *Pool) Close() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.Flush()
+}
+
+// Ready 检查连接池是否已初始化
+func (p *Pool) Ready() bool {
+	return p.ctx != nil
+}
+
+// Active 获取当前活跃连接数
+func (p *Pool) Active() int {
+	return p.freeLen()
+}
+
+// Capacity 获取当前连接池容量
+func (p *Pool) Capacity() int {
+	return p.capacity
+}
+
+// Interval 获取当前连接创建间隔
+func (p *Pool) Interval() time.Duration {
+	return p.interval
+}
+
+// AddError 增加错误计数
+func (p *Pool) AddError() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errCount++
+}
+
+// ErrorCount 获取错误计数
+func (p *Pool) ErrorCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.errCount
+}
+
+// getID 生成唯一的连接ID
+func (p *Pool) getID() string {
+	bytes := make([]byte, 4)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// freeLen 返回当前空闲ID的数量，调用方无需持有p.mu
+func (p *Pool) freeLen() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.freeList.Len()
+}
+
+// pushFreeIfRoom 在未超过maxCap时将ID加入空闲列表并唤醒一个等待者，返回是否加入成功。
+// 入列与信号发送在同一次加锁内完成，避免removeID在两者之间的窗口期内
+// 误将此次信号当作其他ID的补偿信号吸收，导致信号与列表状态失配。
+func (p *Pool) pushFreeIfRoom(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.freeList.Len() >= p.maxCap {
+		return false
+	}
+	elem := p.freeList.PushBack(id)
+	p.freeIndex[id] = elem
+
+	select {
+	case p.freeSem <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// popFree 取出并移除空闲列表头部的ID，调用方应先从p.freeSem消费一个信号
+func (p *Pool) popFree() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	front := p.freeList.Front()
+	if front == nil {
+		return "", false
+	}
+	id := front.Value.(string)
+	p.freeList.Remove(front)
+	delete(p.freeIndex, id)
+	return id, true
+}
+
+// removeID 从空闲ID列表中移除指定ID，调用方须持有p.mu。
+// 由于该ID的出列并非经由p.freeSem消费，这里尽力多吸收一个信号，
+// 以避免后续出现空转的虚假唤醒（即便未完全吸收也不影响正确性）。
+func (p *Pool) removeID(id string) {
+	elem, ok := p.freeIndex[id]
+	if !ok {
+		return
+	}
+	p.freeList.Remove(elem)
+	delete(p.freeIndex, id)
+
+	select {
+	case <-p.freeSem:
+	default:
+	}
+}
+
+// reaper 周期性巡检空闲连接，清理超时或探测失败的连接，并回收限流器中
+// 长期空闲的per-IP条目
+func (p *Pool) reaper() {
+	if p.idleTimeout <= 0 && p.ping == nil && p.rateLimiter == nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.conns.Range(func(key, value any) bool {
+				id := key.(string)
+				entry := value.(connEntry)
+
+				expired := p.idleTimeout > 0 && time.Since(entry.insertedAt) > p.idleTimeout
+				if !expired && p.ping != nil {
+					expired = p.ping(entry.conn) != nil
+				}
+				if expired {
+					p.evict(id)
+				}
+				return true
+			})
+
+			if p.rateLimiter != nil {
+				p.rateLimiter.sweep()
+			}
+		}
+	}
+}
+
+// evict 关闭并从池中移除指定ID的连接
+func (p *Pool) evict(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if value, ok := p.conns.LoadAndDelete(id); ok {
+		entry := value.(connEntry)
+		entry.conn.Close()
+		p.removeID(id)
+		p.releaseRateLimit(entry)
+		p.metrics.recordEviction()
+		if p.hooks.onEvict != nil {
+			p.hooks.onEvict(id)
+		}
+	}
+}
+
+// adjustInterval 根据连接池使用情况动态调整连接创建间隔
+func (p *Pool) adjustInterval() {
+	idle := p.freeLen()
+
+	if idle < p.capacity*2/10 && p.interval > p.minIvl {
+		p.interval -= 100 * time.Millisecond
+		if p.interval < p.minIvl {
+			p.interval = p.minIvl
+		}
+	}
+
+	if idle > p.capacity*8/10 && p.interval < p.maxIvl {
+		p.interval += 100 * time.Millisecond
+		if p.interval > p.maxIvl {
+			p.interval = p.maxIvl
+		}
+	}
+}
+
+// adjustCapacity 根据创建成功率动态调整连接池容量
+func (p *Pool) adjustCapacity(created int) {
+	ratio := float64(created) / float64(p.capacity)
+
+	if ratio < 0.2 && p.capacity > p.minCap {
+		p.capacity--
+	}
+
+	if ratio > 0.8 && p.capacity < p.maxCap {
+		p.capacity++
+	}
+}
+
+// isActive 检查连接是否处于活跃状态
+func (p *Pool) isActive(conn net.Conn) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return false
+	}
+
+	_, err := conn.Read(make([]byte, 1))
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return false
+	}
+
+	if err, ok := err.(net.Error); ok && err.Timeout() {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,148 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestFreeListConcurrentGetPut 并发地放入与取出空闲ID，验证索引化空闲列表替换
+// 原先基于channel重建的removeID后不会出现竞态或状态错乱（配合 -race 运行）。
+func TestFreeListConcurrentGetPut(t *testing.T) {
+	pool := NewClientPool(1, 200, time.Millisecond, time.Millisecond, 0, "0", "", func() (net.Conn, error) {
+		return nil, fmt.Errorf("unused in this test")
+	}, nil, 0, 0)
+
+	const workers = 50
+	const perWorker = 200
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				id := fmt.Sprintf("%d-%d", w, i)
+				client, server := net.Pipe()
+				server.Close()
+
+				pool.conns.Store(id, connEntry{conn: client, insertedAt: time.Now()})
+				pool.pushFreeIfRoom(id)
+
+				if got := pool.ClientGet(id); got != nil {
+					got.Close()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n := pool.freeLen(); n != 0 {
+		t.Fatalf("expected free list to be drained, got %d entries left", n)
+	}
+}
+
+// TestServerGetRacesEviction 并发地将连接推入空闲列表，同时让一部分ID在
+// 被ServerGetContext取走之前就经由reaper使用的removeID路径被回收，
+// 验证pushFreeIfRoom的入列与freeSem发送现在共享同一段临界区后，
+// 不会出现"信号多于空闲项"从而让后续合法的推入被静默丢弃的情形
+// （原问题：freeSem令牌发送曾在p.mu释放之后进行）。
+func TestServerGetRacesEviction(t *testing.T) {
+	pool := NewClientPool(1, 5000, time.Millisecond, time.Millisecond, 0, "0", "", func() (net.Conn, error) {
+		return nil, fmt.Errorf("unused in this test")
+	}, nil, 0, 0)
+	pool.ctx, pool.cancel = context.WithCancel(context.Background())
+	defer pool.cancel()
+
+	const total = 2000
+	var pushed, gotten, evicted int64
+
+	// net.Pipe两端的读写是同步的：只要server端还没关闭也没有数据到达，
+	// client端的isActive探测读会超时而非立即收到EOF，从而被判定为存活。
+	// 若像TestFreeListConcurrentGetPut那样让server端立即Close，
+	// ServerGetContext取出的每一个连接都会在isActive处被判定失活并丢弃，
+	// 导致gotten恒为0、这个测试从未真正验证过accounting不变式。
+	// 这里保留所有server端直到测试结束统一关闭。
+	var serverEndsMu sync.Mutex
+	var serverEnds []net.Conn
+	t.Cleanup(func() {
+		serverEndsMu.Lock()
+		defer serverEndsMu.Unlock()
+		for _, s := range serverEnds {
+			s.Close()
+		}
+	})
+
+	stop := make(chan struct{})
+	var getterWg sync.WaitGroup
+	for g := 0; g < 20; g++ {
+		getterWg.Add(1)
+		go func() {
+			defer getterWg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				_, conn, err := pool.ServerGetContext(ctx)
+				cancel()
+				if err == nil {
+					atomic.AddInt64(&gotten, 1)
+					conn.Close()
+				}
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("id-%d", i)
+			client, server := net.Pipe()
+			serverEndsMu.Lock()
+			serverEnds = append(serverEnds, server)
+			serverEndsMu.Unlock()
+
+			pool.conns.Store(id, connEntry{conn: client, insertedAt: time.Now()})
+			if !pool.pushFreeIfRoom(id) {
+				pool.conns.Delete(id)
+				client.Close()
+				return
+			}
+			atomic.AddInt64(&pushed, 1)
+
+			// 模拟reaper在该ID被ServerGetContext取走之前就将其回收，
+			// 与popFree/freeSem的消费路径直接竞争。
+			if i%3 == 0 {
+				if value, ok := pool.conns.LoadAndDelete(id); ok {
+					value.(connEntry).conn.Close()
+					pool.mu.Lock()
+					pool.removeID(id)
+					pool.mu.Unlock()
+					atomic.AddInt64(&evicted, 1)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 留出时间让getter耗尽剩余的空闲项，再停止getter。
+	time.Sleep(100 * time.Millisecond)
+	close(stop)
+	getterWg.Wait()
+
+	if n := pool.freeLen(); n != 0 {
+		t.Fatalf("expected free list to be drained after race, got %d entries left", n)
+	}
+	if got, want := atomic.LoadInt64(&gotten)+atomic.LoadInt64(&evicted), atomic.LoadInt64(&pushed); got != want {
+		t.Fatalf("accounted for %d connections (gotten+evicted), expected %d (pushed)", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package pool
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// RateLimit 描述服务器连接池在TLS握手前执行的限流与连接配额配置
+type RateLimit struct {
+	PerIPQPS       float64 // 每个源IP每秒产生的新连接令牌数，<=0表示不限制速率
+	PerIPBurst     int     // 每个源IP令牌桶的突发容量，<=0时按1处理
+	PerIPMaxConns  int     // 每个源IP在连接池中同时占用的最大连接数（握手中或空闲待取；连接被Get取走或被回收后即归还配额），<=0表示不限制
+	GlobalMaxConns int     // 连接池全局同时占用的最大连接数（计数口径同PerIPMaxConns），<=0表示不限制
+}
+
+// tokenBucket 是一个简单的令牌桶限流器，按需惰性补充令牌
+type tokenBucket struct {
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+}
+
+// newTokenBucket 创建一个初始装满的令牌桶
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), lastFill: time.Now()}
+}
+
+// allow 尝试消费一个令牌，调用方须持有外层锁
+func (b *tokenBucket) allow() bool {
+	now := time.Now()
+	b.tokens += now.Sub(b.lastFill).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastFill = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// ipLimiterIdleTTL 是没有活跃连接的ipLimiter条目在被回收器清理前可保留的最长时间，
+// 用于避免perIP在源IP不断变化（如NAT池、轮换客户端或低速扫描）时无限增长
+const ipLimiterIdleTTL = 10 * time.Minute
+
+// ipLimiter 维护单个源IP的令牌桶与当前并发连接数
+type ipLimiter struct {
+	bucket   *tokenBucket
+	active   int
+	lastSeen time.Time // 最近一次allow/release的时间，供空闲条目回收使用
+}
+
+// rateLimiter 按源IP与全局维度限制服务器连接池接纳的新连接
+type rateLimiter struct {
+	mu           sync.Mutex
+	cfg          RateLimit
+	perIP        map[string]*ipLimiter
+	globalActive int
+}
+
+// newRateLimiter 根据配置创建限流器
+func newRateLimiter(cfg RateLimit) *rateLimiter {
+	return &rateLimiter{cfg: cfg, perIP: make(map[string]*ipLimiter)}
+}
+
+// allow 在TLS握手之前评估是否接纳来自ip的新连接，接纳则占用相应配额
+func (r *rateLimiter) allow(ip string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cfg.GlobalMaxConns > 0 && r.globalActive >= r.cfg.GlobalMaxConns {
+		return false
+	}
+
+	lim, ok := r.perIP[ip]
+	if !ok {
+		lim = &ipLimiter{bucket: newTokenBucket(r.cfg.PerIPQPS, r.cfg.PerIPBurst)}
+		r.perIP[ip] = lim
+	}
+	lim.lastSeen = time.Now()
+
+	if r.cfg.PerIPMaxConns > 0 && lim.active >= r.cfg.PerIPMaxConns {
+		return false
+	}
+
+	if r.cfg.PerIPQPS > 0 && !lim.bucket.allow() {
+		return false
+	}
+
+	lim.active++
+	r.globalActive++
+	return true
+}
+
+// release 归还此前由 allow 占用的配额，在连接被拒绝存入或离开连接池时调用
+func (r *rateLimiter) release(ip string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.globalActive--
+	if lim, ok := r.perIP[ip]; ok {
+		lim.active--
+		lim.lastSeen = time.Now()
+	}
+}
+
+// sweep 清理长时间没有活跃连接的ipLimiter条目，由连接池的回收器周期性调用，
+// 防止perIP在源IP持续变化的场景下无限增长
+func (r *rateLimiter) sweep() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for ip, lim := range r.perIP {
+		if lim.active <= 0 && time.Since(lim.lastSeen) > ipLimiterIdleTTL {
+			delete(r.perIP, ip)
+		}
+	}
+}
+
+// ipAllowed 判断ip是否落在CIDR允许列表中的任意一个网段内
+func ipAllowed(allowlist []*net.IPNet, ip net.IP) bool {
+	for _, cidr := range allowlist {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}